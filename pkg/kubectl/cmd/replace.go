@@ -17,19 +17,29 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
+	stderrors "errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/golang/glog"
 
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
 	"k8s.io/kubernetes/pkg/kubectl"
 	"k8s.io/kubernetes/pkg/kubectl/cmd/templates"
 	cmdutil "k8s.io/kubernetes/pkg/kubectl/cmd/util"
@@ -75,6 +85,14 @@ type ReplaceOpts struct {
 	changeCause      string
 	validate         bool
 
+	ServerSideApply bool
+	ForceConflicts  bool
+	FieldManager    string
+
+	Wait        bool
+	WaitTimeout time.Duration
+	Context     context.Context
+
 	Schema      validation.Schema
 	Builder     func() *resource.Builder
 	BuilderArgs []string
@@ -120,8 +138,15 @@ func NewCmdReplace(f cmdutil.Factory, out, errOut io.Writer) *cobra.Command {
 	cmd.MarkFlagRequired("filename")
 	cmd.Flags().BoolVar(&options.DeleteOptions.ForceDeletion, "force", options.DeleteOptions.ForceDeletion, "Delete and re-create the specified resource")
 	cmd.Flags().BoolVar(&options.DeleteOptions.Cascade, "cascade", options.DeleteOptions.Cascade, "Only relevant during a force replace. If true, cascade the deletion of the resources managed by this resource (e.g. Pods created by a ReplicationController).")
+	cmd.Flags().MarkDeprecated("cascade", "use --propagation-policy=background or --propagation-policy=orphan instead")
+	cmd.Flags().StringVar(&options.DeleteOptions.PropagationPolicy, "propagation-policy", options.DeleteOptions.PropagationPolicy, "Only relevant during a force replace. Whether and how garbage collection is performed. The supported values are 'orphan', 'background', or 'foreground'.")
 	cmd.Flags().IntVar(&options.DeleteOptions.GracePeriod, "grace-period", options.DeleteOptions.GracePeriod, "Only relevant during a force replace. Period of time in seconds given to the old resource to terminate gracefully. Ignored if negative.")
 	cmd.Flags().DurationVar(&options.DeleteOptions.Timeout, "timeout", options.DeleteOptions.Timeout, "Only relevant during a force replace. The length of time to wait before giving up on a delete of the old resource, zero means determine a timeout from the size of the object. Any other values should contain a corresponding time unit (e.g. 1s, 2m, 3h).")
+	cmd.Flags().BoolVar(&options.ServerSideApply, "server-side", options.ServerSideApply, "If true, replace will use the server-side apply patch instead of the client-side replace.")
+	cmd.Flags().StringVar(&options.FieldManager, "field-manager", "kubectl-replace", "Name of the manager used to track field ownership. Only relevant with --server-side.")
+	cmd.Flags().BoolVar(&options.ForceConflicts, "force-conflicts", options.ForceConflicts, "If true, server-side apply will force the replace to happen even if there are conflicting managers. Only relevant with --server-side.")
+	cmd.Flags().BoolVar(&options.Wait, "wait", options.Wait, "If true, wait for each replaced resource to report a healthy, ready state before returning.")
+	cmd.Flags().DurationVar(&options.WaitTimeout, "wait-timeout", 5*time.Minute, "Only relevant with --wait. The length of time to wait for each resource to become healthy before giving up, zero means check forever.")
 	cmdutil.AddValidateFlags(cmd)
 	cmdutil.AddApplyAnnotationFlags(cmd)
 	cmdutil.AddRecordFlag(cmd)
@@ -134,6 +159,7 @@ func (o *ReplaceOpts) Complete(f cmdutil.Factory, cmd *cobra.Command, args []str
 	o.validate = cmdutil.GetFlagBool(cmd, "validate")
 	o.changeCause = f.Command(cmd, false)
 	o.createAnnotation = cmdutil.GetFlagBool(cmd, cmdutil.ApplyAnnotationsFlag)
+	o.Context = cmd.Context()
 
 	o.ShouldRecord = func(info *resource.Info) bool {
 		return cmdutil.ShouldRecord(cmd, info)
@@ -178,6 +204,39 @@ func (o *ReplaceOpts) Complete(f cmdutil.Factory, cmd *cobra.Command, args []str
 		return err
 	}
 
+	if o.ServerSideApply {
+		discoveryClient, err := f.DiscoveryClient()
+		if err != nil {
+			return err
+		}
+		if err := checkServerSupportsServerSideApply(discoveryClient); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkServerSupportsServerSideApply returns an error if the connected
+// apiserver doesn't understand the apply-patch content type used by
+// --server-side (added in Kubernetes 1.16).
+func checkServerSupportsServerSideApply(discoveryClient discovery.DiscoveryInterface) error {
+	serverVersion, err := discoveryClient.ServerVersion()
+	if err != nil {
+		return fmt.Errorf("unable to determine whether the server supports --server-side: %v", err)
+	}
+
+	major, err := strconv.Atoi(serverVersion.Major)
+	if err != nil {
+		return fmt.Errorf("unable to parse server version %q: %v", serverVersion.String(), err)
+	}
+	minor, err := strconv.Atoi(strings.TrimSuffix(serverVersion.Minor, "+"))
+	if err != nil {
+		return fmt.Errorf("unable to parse server version %q: %v", serverVersion.String(), err)
+	}
+	if major < 1 || (major == 1 && minor < 16) {
+		return fmt.Errorf("server version %s does not support --server-side apply (requires 1.16 or later)", serverVersion.String())
+	}
 	return nil
 }
 
@@ -190,6 +249,36 @@ func (o *ReplaceOpts) Validate(cmd *cobra.Command) error {
 		return fmt.Errorf("--timeout must have --force specified")
 	}
 
+	if o.DeleteOptions.PropagationPolicy != "" && !o.DeleteOptions.ForceDeletion {
+		return fmt.Errorf("--propagation-policy must have --force specified")
+	}
+
+	if o.ServerSideApply && o.DeleteOptions.ForceDeletion {
+		return fmt.Errorf("--force and --server-side are mutually exclusive")
+	}
+
+	if o.ForceConflicts && !o.ServerSideApply {
+		return fmt.Errorf("--force-conflicts must have --server-side specified")
+	}
+
+	if cmd.Flags().Changed("wait-timeout") && !o.Wait {
+		return fmt.Errorf("--wait-timeout must have --wait specified")
+	}
+
+	if cmd.Flags().Changed("cascade") {
+		if o.DeleteOptions.PropagationPolicy != "" {
+			return fmt.Errorf("--propagation-policy and --cascade are mutually exclusive")
+		}
+		glog.Warningf("--cascade is deprecated (it is now translated to the equivalent --propagation-policy), please use --propagation-policy=%s instead", cascadeToPropagationPolicy(o.DeleteOptions.Cascade))
+		o.DeleteOptions.PropagationPolicy = string(cascadeToPropagationPolicy(o.DeleteOptions.Cascade))
+	}
+
+	switch metav1.DeletionPropagation(o.DeleteOptions.PropagationPolicy) {
+	case "", metav1.DeletePropagationForeground, metav1.DeletePropagationBackground, metav1.DeletePropagationOrphan:
+	default:
+		return fmt.Errorf("invalid --propagation-policy %q: must be one of foreground, background, or orphan", o.DeleteOptions.PropagationPolicy)
+	}
+
 	if cmdutil.IsFilenameSliceEmpty(o.FileNameOptions.Filenames) {
 		return cmdutil.UsageErrorf(cmd, "Must specify --filename to replace")
 	}
@@ -197,11 +286,24 @@ func (o *ReplaceOpts) Validate(cmd *cobra.Command) error {
 	return nil
 }
 
+// cascadeToPropagationPolicy maps the deprecated --cascade bool onto the
+// equivalent --propagation-policy value.
+func cascadeToPropagationPolicy(cascade bool) metav1.DeletionPropagation {
+	if cascade {
+		return metav1.DeletePropagationBackground
+	}
+	return metav1.DeletePropagationOrphan
+}
+
 func (o *ReplaceOpts) Run() error {
 	if o.DeleteOptions.ForceDeletion {
 		return o.forceReplace()
 	}
 
+	if o.ServerSideApply {
+		return o.serverSideReplace()
+	}
+
 	r := o.Builder().
 		Unstructured().
 		Schema(o.Schema).
@@ -214,7 +316,8 @@ func (o *ReplaceOpts) Run() error {
 		return err
 	}
 
-	return o.Result.Visit(func(info *resource.Info, err error) error {
+	var replaced []*resource.Info
+	err := r.Visit(func(info *resource.Info, err error) error {
 		if err != nil {
 			return err
 		}
@@ -236,8 +339,93 @@ func (o *ReplaceOpts) Run() error {
 		}
 
 		info.Refresh(obj, true)
+		replaced = append(replaced, info)
 		return o.PrintObj(info.AsVersioned())
 	})
+	if err != nil {
+		return err
+	}
+
+	return o.waitForHealthy(replaced)
+}
+
+// serverSideReplace replaces each object by issuing a server-side apply
+// patch (application/apply-patch+yaml) instead of the client-side
+// resource.NewHelper(...).Replace(...) used by the default path.
+func (o *ReplaceOpts) serverSideReplace() error {
+	r := o.Builder().
+		Unstructured().
+		Schema(o.Schema).
+		ContinueOnError().
+		NamespaceParam(o.Namespace).DefaultNamespace().
+		FilenameParam(o.EnforceNamespace, o.FileNameOptions).
+		Flatten().
+		Do()
+	if err := r.Err(); err != nil {
+		return err
+	}
+
+	var replaced []*resource.Info
+	err := r.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if o.ShouldRecord(info) {
+			if err := cmdutil.RecordChangeCause(info.Object, o.changeCause); err != nil {
+				return cmdutil.AddSourceToErr("replacing", info.Source, err)
+			}
+		}
+
+		data, err := runtime.Encode(cmdutil.InternalVersionJSONEncoder(), info.Object)
+		if err != nil {
+			return cmdutil.AddSourceToErr("replacing", info.Source, err)
+		}
+
+		helper := resource.NewHelper(info.Client, info.Mapping)
+		obj, err := helper.Patch(info.Namespace, info.Name, types.ApplyPatchType, data, &metav1.PatchOptions{
+			FieldManager: o.FieldManager,
+			Force:        &o.ForceConflicts,
+		})
+		if err != nil {
+			if errors.IsConflict(err) {
+				return cmdutil.AddSourceToErr("replacing", info.Source, conflictError(err))
+			}
+			return cmdutil.AddSourceToErr("replacing", info.Source, err)
+		}
+
+		info.Refresh(obj, true)
+		replaced = append(replaced, info)
+		return o.PrintObj(info.AsVersioned())
+	})
+	if err != nil {
+		return err
+	}
+
+	return o.waitForHealthy(replaced)
+}
+
+// conflictError re-wraps a 409 apply conflict, surfacing the disputed field
+// paths and the managers that currently own them instead of a bare error
+// string.
+func conflictError(err error) error {
+	status, ok := err.(errors.APIStatus)
+	if !ok {
+		return err
+	}
+
+	details := status.Status().Details
+	if details == nil || len(details.Causes) == 0 {
+		return err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Apply failed with conflicts:")
+	for _, cause := range details.Causes {
+		fmt.Fprintf(&b, "\n  - %s: %s", cause.Field, cause.Message)
+	}
+	b.WriteString("\n\nre-run with --force-conflicts to force the replace to take ownership of the conflicting fields")
+	return stderrors.New(b.String())
 }
 
 func (o *ReplaceOpts) forceReplace() error {
@@ -271,31 +459,47 @@ func (o *ReplaceOpts) forceReplace() error {
 
 	var err error
 
-	// By default use a reaper to delete all related resources.
-	if o.DeleteOptions.Cascade {
+	propagationPolicy := metav1.DeletionPropagation(o.DeleteOptions.PropagationPolicy)
+	if propagationPolicy != "" {
+		// A propagation policy was requested: delete server-side with that
+		// policy instead of going through the reaper.
+		err = o.DeleteOptions.DeleteResult(r)
+	} else if o.DeleteOptions.Cascade {
+		// By default use a reaper to delete all related resources.
 		glog.Warningf("\"cascade\" is set, kubectl will delete and re-create all resources managed by this resource (e.g. Pods created by a ReplicationController). Consider using \"kubectl rolling-update\" if you want to update a ReplicationController together with its Pods.")
 		err = o.DeleteOptions.ReapResult(r, o.DeleteOptions.Cascade, false)
 	} else {
 		err = o.DeleteOptions.DeleteResult(r)
 	}
+	if err != nil {
+		return err
+	}
 
+	timeout := o.DeleteOptions.Timeout
 	if timeout == 0 {
 		timeout = kubectl.Timeout
 	}
-	err = r.Visit(func(info *resource.Info, err error) error {
-		if err != nil {
-			return err
-		}
 
-		return wait.PollImmediate(kubectl.Interval, timeout, func() (bool, error) {
-			if err := info.Get(); !errors.IsNotFound(err) {
-				return false, err
+	// Wait for the object to disappear before re-creating, as this path has
+	// always done. The one exception is an explicit "background"/"orphan"
+	// propagation policy, which returns as soon as the delete call returns
+	// by design, so there is nothing to wait for there.
+	if propagationPolicy != metav1.DeletePropagationBackground && propagationPolicy != metav1.DeletePropagationOrphan {
+		err = r.Visit(func(info *resource.Info, err error) error {
+			if err != nil {
+				return err
 			}
-			return true, nil
+
+			return wait.PollImmediate(kubectl.Interval, timeout, func() (bool, error) {
+				if err := info.Get(); !errors.IsNotFound(err) {
+					return false, err
+				}
+				return true, nil
+			})
 		})
-	})
-	if err != nil {
-		return err
+		if err != nil {
+			return err
+		}
 	}
 
 	r = o.Builder().
@@ -312,6 +516,7 @@ func (o *ReplaceOpts) forceReplace() error {
 	}
 
 	count := 0
+	var replaced []*resource.Info
 	err = r.Visit(func(info *resource.Info, err error) error {
 		if err != nil {
 			return err
@@ -334,6 +539,7 @@ func (o *ReplaceOpts) forceReplace() error {
 
 		count++
 		info.Refresh(obj, true)
+		replaced = append(replaced, info)
 		return o.PrintObj(info.AsVersioned())
 	})
 	if err != nil {
@@ -342,5 +548,62 @@ func (o *ReplaceOpts) forceReplace() error {
 	if count == 0 {
 		return fmt.Errorf("no objects passed to replace")
 	}
-	return nil
+
+	return o.waitForHealthy(replaced)
+}
+
+// waitForHealthy polls each replaced object until healthCheckerFor(its
+// GroupKind) reports it healthy, aggregating per-object errors and printing
+// progress to o.ErrOut as it goes. It is a no-op unless --wait was passed.
+func (o *ReplaceOpts) waitForHealthy(infos []*resource.Info) error {
+	if !o.Wait || len(infos) == 0 {
+		return nil
+	}
+
+	parent := o.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	var errs []error
+	for _, info := range infos {
+		name := fmt.Sprintf("%s/%s", info.Mapping.Resource.Resource, info.Name)
+		fmt.Fprintf(o.ErrOut, "waiting for %s to report healthy...\n", name)
+
+		checker := healthCheckerFor(info.Mapping.GroupVersionKind.GroupKind())
+
+		var ctx context.Context
+		var cancel context.CancelFunc
+		if o.WaitTimeout > 0 {
+			ctx, cancel = context.WithTimeout(parent, o.WaitTimeout)
+		} else {
+			ctx, cancel = context.WithCancel(parent)
+		}
+		err := wait.PollImmediateUntil(kubectl.Interval, func() (bool, error) {
+			if err := info.Get(); err != nil {
+				return false, err
+			}
+			u, ok := info.Object.(*unstructured.Unstructured)
+			if !ok {
+				// Not an unstructured object (e.g. no server-side status was
+				// returned); nothing to assess, treat as healthy.
+				return true, nil
+			}
+			return checker(u)
+		}, ctx.Done())
+		cancel()
+
+		if err != nil {
+			if err == wait.ErrWaitTimeout {
+				err = fmt.Errorf("timed out waiting for %s to become healthy after %s", name, o.WaitTimeout)
+			}
+			fmt.Fprintf(o.ErrOut, "%s: %v\n", name, err)
+			errs = append(errs, err)
+			continue
+		}
+
+		fmt.Fprintf(o.ErrOut, "%s is healthy\n", name)
+	}
+
+	return utilerrors.NewAggregate(errs)
 }
@@ -0,0 +1,155 @@
+/*
+Copyright 2014 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// healthChecker reports whether obj, the freshly-refreshed live object, has
+// reached a healthy state. A non-nil error indicates a terminal failure
+// (e.g. a Job that reached Failed) that should stop waiting immediately
+// instead of polling until the timeout.
+type healthChecker func(obj *unstructured.Unstructured) (healthy bool, err error)
+
+// healthCheckerFor returns the health assessor for gk. Kinds with no
+// specific signals fall back to genericHealthy.
+func healthCheckerFor(gk schema.GroupKind) healthChecker {
+	switch gk.Kind {
+	case "Deployment":
+		return deploymentHealthy
+	case "StatefulSet":
+		return statefulSetHealthy
+	case "DaemonSet":
+		return daemonSetHealthy
+	case "Pod":
+		return podHealthy
+	case "Job":
+		return jobHealthy
+	case "Service":
+		return serviceHealthy
+	case "PersistentVolumeClaim":
+		return pvcHealthy
+	default:
+		return genericHealthy
+	}
+}
+
+func deploymentHealthy(obj *unstructured.Unstructured) (bool, error) {
+	observedGeneration, _, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if observedGeneration < obj.GetGeneration() {
+		return false, nil
+	}
+
+	replicas, _, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	updatedReplicas, _, _ := unstructured.NestedInt64(obj.Object, "status", "updatedReplicas")
+	if updatedReplicas != replicas {
+		return false, nil
+	}
+
+	return conditionTrue(obj, "Available"), nil
+}
+
+func statefulSetHealthy(obj *unstructured.Unstructured) (bool, error) {
+	observedGeneration, _, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if observedGeneration < obj.GetGeneration() {
+		return false, nil
+	}
+
+	replicas, _, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	updatedReplicas, _, _ := unstructured.NestedInt64(obj.Object, "status", "updatedReplicas")
+	readyReplicas, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+	return updatedReplicas == replicas && readyReplicas == replicas, nil
+}
+
+func daemonSetHealthy(obj *unstructured.Unstructured) (bool, error) {
+	observedGeneration, _, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if observedGeneration < obj.GetGeneration() {
+		return false, nil
+	}
+
+	desired, _, _ := unstructured.NestedInt64(obj.Object, "status", "desiredNumberScheduled")
+	updated, _, _ := unstructured.NestedInt64(obj.Object, "status", "updatedNumberScheduled")
+	ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "numberReady")
+	return updated == desired && ready == desired, nil
+}
+
+func podHealthy(obj *unstructured.Unstructured) (bool, error) {
+	return conditionTrue(obj, "Ready"), nil
+}
+
+func jobHealthy(obj *unstructured.Unstructured) (bool, error) {
+	if conditionTrue(obj, "Complete") {
+		return true, nil
+	}
+	if conditionTrue(obj, "Failed") {
+		return false, fmt.Errorf("job failed")
+	}
+	return false, nil
+}
+
+func serviceHealthy(obj *unstructured.Unstructured) (bool, error) {
+	svcType, _, _ := unstructured.NestedString(obj.Object, "spec", "type")
+	if svcType != "LoadBalancer" {
+		return true, nil
+	}
+
+	ingress, _, _ := unstructured.NestedSlice(obj.Object, "status", "loadBalancer", "ingress")
+	return len(ingress) > 0, nil
+}
+
+func pvcHealthy(obj *unstructured.Unstructured) (bool, error) {
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	return phase == "Bound", nil
+}
+
+// genericHealthy is used for kinds with no dedicated signals above. An
+// object that doesn't surface status.conditions at all (e.g. a ConfigMap)
+// is treated as healthy as soon as it exists.
+func genericHealthy(obj *unstructured.Unstructured) (bool, error) {
+	conditions, found, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if !found {
+		return true, nil
+	}
+	return conditionTrueIn(conditions, "Ready") || conditionTrueIn(conditions, "Available"), nil
+}
+
+func conditionTrue(obj *unstructured.Unstructured, condType string) bool {
+	conditions, found, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if !found {
+		return false
+	}
+	return conditionTrueIn(conditions, condType)
+}
+
+func conditionTrueIn(conditions []interface{}, condType string) bool {
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if condition["type"] != condType {
+			continue
+		}
+		status, _ := condition["status"].(string)
+		return status == "True"
+	}
+	return false
+}
@@ -0,0 +1,323 @@
+/*
+Copyright 2014 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newHealthTestObject(generation int64, spec, status map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec":   spec,
+		"status": status,
+	}}
+	obj.SetGeneration(generation)
+	return obj
+}
+
+func conditions(entries ...map[string]interface{}) []interface{} {
+	out := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, e)
+	}
+	return out
+}
+
+func condition(condType, status string) map[string]interface{} {
+	return map[string]interface{}{"type": condType, "status": status}
+}
+
+func TestDeploymentHealthy(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    map[string]interface{}
+		status  map[string]interface{}
+		healthy bool
+	}{
+		{
+			name: "stale generation",
+			spec: map[string]interface{}{"replicas": int64(3)},
+			status: map[string]interface{}{
+				"observedGeneration": int64(0),
+				"updatedReplicas":    int64(3),
+				"conditions":         conditions(condition("Available", "True")),
+			},
+			healthy: false,
+		},
+		{
+			name: "rollout in progress",
+			spec: map[string]interface{}{"replicas": int64(3)},
+			status: map[string]interface{}{
+				"observedGeneration": int64(1),
+				"updatedReplicas":    int64(2),
+				"conditions":         conditions(condition("Available", "True")),
+			},
+			healthy: false,
+		},
+		{
+			name: "available condition missing",
+			spec: map[string]interface{}{"replicas": int64(3)},
+			status: map[string]interface{}{
+				"observedGeneration": int64(1),
+				"updatedReplicas":    int64(3),
+				"conditions":         conditions(condition("Progressing", "True")),
+			},
+			healthy: false,
+		},
+		{
+			name: "rolled out",
+			spec: map[string]interface{}{"replicas": int64(3)},
+			status: map[string]interface{}{
+				"observedGeneration": int64(1),
+				"updatedReplicas":    int64(3),
+				"conditions":         conditions(condition("Available", "True")),
+			},
+			healthy: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			obj := newHealthTestObject(1, test.spec, test.status)
+			healthy, err := deploymentHealthy(obj)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if healthy != test.healthy {
+				t.Errorf("expected healthy=%v, got %v", test.healthy, healthy)
+			}
+		})
+	}
+}
+
+func TestStatefulSetHealthy(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  map[string]interface{}
+		healthy bool
+	}{
+		{
+			name: "stale generation",
+			status: map[string]interface{}{
+				"observedGeneration": int64(0),
+				"updatedReplicas":    int64(3),
+				"readyReplicas":      int64(3),
+			},
+			healthy: false,
+		},
+		{
+			name: "not all ready",
+			status: map[string]interface{}{
+				"observedGeneration": int64(1),
+				"updatedReplicas":    int64(3),
+				"readyReplicas":      int64(2),
+			},
+			healthy: false,
+		},
+		{
+			name: "rolled out",
+			status: map[string]interface{}{
+				"observedGeneration": int64(1),
+				"updatedReplicas":    int64(3),
+				"readyReplicas":      int64(3),
+			},
+			healthy: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := map[string]interface{}{"replicas": int64(3)}
+			obj := newHealthTestObject(1, spec, test.status)
+			healthy, err := statefulSetHealthy(obj)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if healthy != test.healthy {
+				t.Errorf("expected healthy=%v, got %v", test.healthy, healthy)
+			}
+		})
+	}
+}
+
+func TestDaemonSetHealthy(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  map[string]interface{}
+		healthy bool
+	}{
+		{
+			// A replace that doesn't change the pod template (e.g. an
+			// unrelated metadata edit) leaves desired/updated/ready at the
+			// prior rollout's steady-state counts even though the
+			// controller hasn't observed the new generation yet.
+			name: "stale generation with steady-state counts",
+			status: map[string]interface{}{
+				"observedGeneration":     int64(0),
+				"desiredNumberScheduled": int64(3),
+				"updatedNumberScheduled": int64(3),
+				"numberReady":            int64(3),
+			},
+			healthy: false,
+		},
+		{
+			name: "rollout in progress",
+			status: map[string]interface{}{
+				"observedGeneration":     int64(1),
+				"desiredNumberScheduled": int64(3),
+				"updatedNumberScheduled": int64(1),
+				"numberReady":            int64(3),
+			},
+			healthy: false,
+		},
+		{
+			name: "rolled out",
+			status: map[string]interface{}{
+				"observedGeneration":     int64(1),
+				"desiredNumberScheduled": int64(3),
+				"updatedNumberScheduled": int64(3),
+				"numberReady":            int64(3),
+			},
+			healthy: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			obj := newHealthTestObject(1, nil, test.status)
+			healthy, err := daemonSetHealthy(obj)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if healthy != test.healthy {
+				t.Errorf("expected healthy=%v, got %v", test.healthy, healthy)
+			}
+		})
+	}
+}
+
+func TestPodHealthy(t *testing.T) {
+	notReady := newHealthTestObject(1, nil, map[string]interface{}{
+		"conditions": conditions(condition("Ready", "False")),
+	})
+	if healthy, err := podHealthy(notReady); err != nil || healthy {
+		t.Errorf("expected unhealthy pod, got healthy=%v err=%v", healthy, err)
+	}
+
+	ready := newHealthTestObject(1, nil, map[string]interface{}{
+		"conditions": conditions(condition("Ready", "True")),
+	})
+	if healthy, err := podHealthy(ready); err != nil || !healthy {
+		t.Errorf("expected healthy pod, got healthy=%v err=%v", healthy, err)
+	}
+}
+
+func TestJobHealthy(t *testing.T) {
+	running := newHealthTestObject(1, nil, map[string]interface{}{
+		"conditions": conditions(),
+	})
+	if healthy, err := jobHealthy(running); err != nil || healthy {
+		t.Errorf("expected still-running job to be unhealthy with no error, got healthy=%v err=%v", healthy, err)
+	}
+
+	complete := newHealthTestObject(1, nil, map[string]interface{}{
+		"conditions": conditions(condition("Complete", "True")),
+	})
+	if healthy, err := jobHealthy(complete); err != nil || !healthy {
+		t.Errorf("expected complete job to be healthy, got healthy=%v err=%v", healthy, err)
+	}
+
+	failed := newHealthTestObject(1, nil, map[string]interface{}{
+		"conditions": conditions(condition("Failed", "True")),
+	})
+	if healthy, err := jobHealthy(failed); err == nil || healthy {
+		t.Errorf("expected failed job to report an error, got healthy=%v err=%v", healthy, err)
+	}
+}
+
+func TestServiceHealthy(t *testing.T) {
+	clusterIP := newHealthTestObject(1, map[string]interface{}{"type": "ClusterIP"}, map[string]interface{}{})
+	if healthy, err := serviceHealthy(clusterIP); err != nil || !healthy {
+		t.Errorf("expected non-LoadBalancer service to be healthy immediately, got healthy=%v err=%v", healthy, err)
+	}
+
+	pending := newHealthTestObject(1, map[string]interface{}{"type": "LoadBalancer"}, map[string]interface{}{
+		"loadBalancer": map[string]interface{}{},
+	})
+	if healthy, err := serviceHealthy(pending); err != nil || healthy {
+		t.Errorf("expected LoadBalancer service with no ingress to be unhealthy, got healthy=%v err=%v", healthy, err)
+	}
+
+	assigned := newHealthTestObject(1, map[string]interface{}{"type": "LoadBalancer"}, map[string]interface{}{
+		"loadBalancer": map[string]interface{}{
+			"ingress": []interface{}{map[string]interface{}{"ip": "203.0.113.1"}},
+		},
+	})
+	if healthy, err := serviceHealthy(assigned); err != nil || !healthy {
+		t.Errorf("expected LoadBalancer service with ingress to be healthy, got healthy=%v err=%v", healthy, err)
+	}
+}
+
+func TestPVCHealthy(t *testing.T) {
+	pending := newHealthTestObject(1, nil, map[string]interface{}{"phase": "Pending"})
+	if healthy, err := pvcHealthy(pending); err != nil || healthy {
+		t.Errorf("expected pending PVC to be unhealthy, got healthy=%v err=%v", healthy, err)
+	}
+
+	bound := newHealthTestObject(1, nil, map[string]interface{}{"phase": "Bound"})
+	if healthy, err := pvcHealthy(bound); err != nil || !healthy {
+		t.Errorf("expected bound PVC to be healthy, got healthy=%v err=%v", healthy, err)
+	}
+}
+
+func TestGenericHealthy(t *testing.T) {
+	noConditions := newHealthTestObject(1, nil, map[string]interface{}{})
+	if healthy, err := genericHealthy(noConditions); err != nil || !healthy {
+		t.Errorf("expected object with no conditions to be healthy immediately, got healthy=%v err=%v", healthy, err)
+	}
+
+	notReady := newHealthTestObject(1, nil, map[string]interface{}{
+		"conditions": conditions(condition("Ready", "False")),
+	})
+	if healthy, err := genericHealthy(notReady); err != nil || healthy {
+		t.Errorf("expected object with Ready=False to be unhealthy, got healthy=%v err=%v", healthy, err)
+	}
+
+	ready := newHealthTestObject(1, nil, map[string]interface{}{
+		"conditions": conditions(condition("Ready", "True")),
+	})
+	if healthy, err := genericHealthy(ready); err != nil || !healthy {
+		t.Errorf("expected object with Ready=True to be healthy, got healthy=%v err=%v", healthy, err)
+	}
+}
+
+func TestHealthCheckerFor(t *testing.T) {
+	tests := []struct {
+		kind string
+	}{
+		{"Deployment"}, {"StatefulSet"}, {"DaemonSet"}, {"Pod"}, {"Job"}, {"Service"}, {"PersistentVolumeClaim"}, {"ConfigMap"},
+	}
+
+	for _, test := range tests {
+		if healthCheckerFor(schema.GroupKind{Kind: test.kind}) == nil {
+			t.Errorf("expected a non-nil health checker for kind %q", test.kind)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2014 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCascadeToPropagationPolicy(t *testing.T) {
+	if got := cascadeToPropagationPolicy(true); got != metav1.DeletePropagationBackground {
+		t.Errorf("cascade=true: expected %q, got %q", metav1.DeletePropagationBackground, got)
+	}
+	if got := cascadeToPropagationPolicy(false); got != metav1.DeletePropagationOrphan {
+		t.Errorf("cascade=false: expected %q, got %q", metav1.DeletePropagationOrphan, got)
+	}
+}
+
+func TestConflictError(t *testing.T) {
+	nonConflict := fmt.Errorf("boom")
+	if got := conflictError(nonConflict); got != nonConflict {
+		t.Errorf("expected a non-APIStatus error to be returned unchanged, got %v", got)
+	}
+
+	noDetails := &errors.StatusError{ErrStatus: metav1.Status{Reason: metav1.StatusReasonConflict}}
+	if got := conflictError(noDetails); got != error(noDetails) {
+		t.Errorf("expected an error with no conflict details to be returned unchanged, got %v", got)
+	}
+
+	withDetails := &errors.StatusError{ErrStatus: metav1.Status{
+		Reason: metav1.StatusReasonConflict,
+		Details: &metav1.StatusDetails{
+			Causes: []metav1.StatusCause{
+				{Field: "spec.replicas", Message: `conflict with "kubectl-scale"`},
+				{Field: "spec.template.spec.containers[0].image", Message: `conflict with "kubectl-edit"`},
+			},
+		},
+	}}
+
+	msg := conflictError(withDetails).Error()
+	for _, want := range []string{
+		`spec.replicas: conflict with "kubectl-scale"`,
+		`spec.template.spec.containers[0].image: conflict with "kubectl-edit"`,
+		"--force-conflicts",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("expected error message to contain %q, got: %s", want, msg)
+		}
+	}
+
+	// A "%" in a cause's message must survive unmangled: conflictError must
+	// not feed the built string through a format verb.
+	withPercent := &errors.StatusError{ErrStatus: metav1.Status{
+		Reason: metav1.StatusReasonConflict,
+		Details: &metav1.StatusDetails{
+			Causes: []metav1.StatusCause{
+				{Field: "metadata.annotations", Message: `100% owned by "other-manager"`},
+			},
+		},
+	}}
+	if msg := conflictError(withPercent).Error(); !strings.Contains(msg, `100% owned by "other-manager"`) {
+		t.Errorf("expected literal %% to survive formatting, got: %s", msg)
+	}
+}